@@ -1,213 +1,502 @@
 package main
 
 import (
-	"encoding/hex"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/google/gousb"
-	"image/color"
 	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
 	"strconv"
-	"strings"
-)
+	"syscall"
+	"time"
 
-const (
-	vendorId          = gousb.ID(0x046d) // Logitech, Inc.
-	productId         = gousb.ID(0xc084) // G102 and G203 Prodigy Gaming Mouse
-	format            = "11ff0e%s000000000000"
-	defaultRate       = 10000
-	defaultBrightness = 100
+	"github.com/karlovskiy/gled/pkg/gled"
 )
 
-var (
-	debug = flag.Int("debug", 0, "libusb debug level (0..3)")
-)
+// errNoDaemon indicates no daemon is listening on the socket, so the caller
+// should fall back to driving the device directly.
+var errNoDaemon = errors.New("no daemon socket")
+
+// socketCommandTimeout bounds the whole round trip of a daemon socket
+// command (write + reply), not just the initial dial, so a stuck or
+// overloaded daemon can't hang the CLI indefinitely.
+const socketCommandTimeout = 5 * time.Second
 
 func main() {
-	flag.Usage = func() {
-		fmt.Print(`Logitech G102 and G203 Prodigy Mouse LED control
+	if len(os.Args) < 2 {
+		runApply(nil)
+		return
+	}
+
+	mode, args := os.Args[1], os.Args[2:]
+	switch mode {
+	case "solid":
+		runSolid(args)
+	case "cycle":
+		runCycle(args)
+	case "breathe":
+		runBreathe(args)
+	case "intro":
+		runIntro(args)
+	case "apply":
+		runApply(args)
+	case "list":
+		runList(args)
+	case "devices":
+		runDevices(args)
+	case "daemon":
+		runDaemonCmd(args)
+	case "timeline":
+		runTimelineCmd(args)
+	case "-h", "-help", "--help", "help":
+		printUsage()
+	default:
+		printUsage()
+		log.Fatalf("Unknown mode: %q", mode)
+	}
+}
+
+func printUsage() {
+	fmt.Fprint(os.Stderr, `Logitech G102 and G203 Prodigy Mouse LED control
 
 Usage:
-  gled solid <color>                         Solid color mode
-  gled cycle <rate> <brightness>             Cycle through all colors
-  gled breathe <color> <rate> <brightness>   Single color breathing
-  gled intro <toggle>                        Enable/disable startup effect
+  gled solid [-color <color>] [<color>]
+  gled cycle [-rate <rate>] [-brightness <brightness>] [<rate> [<brightness>]]
+  gled breathe [-color <color>] [-rate <rate>] [-brightness <brightness>] [<color> [<rate> [<brightness>]]]
+  gled intro [-toggle <toggle>] [<toggle>]
+  gled apply [<profile>]
+  gled list
+  gled devices
+  gled daemon
+  gled timeline <name>
+  gled
+
+Run 'gled <command> -h' for flags specific to a command.
 
 Arguments:
   color        RRGGBB (RGB hex value)
   rate         100-60000 (Number of milliseconds. Default: 10000ms)
   brightness   0-100 (Percentage. Default: 100%)
   toggle       on|off
+  profile      Name of a profile defined in the config file
+  name         Name of a timeline defined in the config file
+
+Every command also accepts:
+  -debug <0..3>               libusb debug level. Default: 0
+  -device <name|vid:pid>      Target a specific mouse when several are connected
+
+Config:
+  Profiles and timelines are read from ~/.config/gled/config.yml (or
+  $XDG_CONFIG_HOME/gled/config.yml), e.g.:
+
+    default_profile: work
+    profiles:
+      work:
+        mode: breathe
+        color: 00ff88
+        rate: 4000
+        brightness: 60
+      gaming:
+        mode: cycle
+        rate: 2000
+        brightness: 100
+    timelines:
+      pulse-alert:
+        - mode: solid
+          color: ff0000
+          hold: 500ms
+        - mode: solid
+          color: "000000"
+          hold: 500ms
+        - repeat: 5
 
-Flags:
-  gled -debug <0..3> ...                     Debug level for libusb. Default: 0
+  While 'gled daemon' is running, commands are sent over its socket instead of
+  claiming the USB device directly.
 `)
+}
+
+// commonFlags registers the flags every subcommand accepts: -debug and
+// -device.
+func commonFlags(fs *flag.FlagSet) (debug *int, device *string) {
+	debug = fs.Int("debug", 0, "libusb debug level (0..3)")
+	device = fs.String("device", "", "target device: profile name or vid:pid (e.g. 046d:c084); required if more than one supported mouse is connected")
+	return debug, device
+}
+
+// usageFor builds an fs.Usage that prints a one-line synopsis and the
+// subcommand's flag defaults.
+func usageFor(fs *flag.FlagSet, synopsis string) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s\n\nFlags:\n", synopsis)
+		fs.PrintDefaults()
 	}
+}
 
-	flag.Parse()
-	mode := flag.Arg(0)
-	switch mode {
-	case "solid":
-		setSolid()
-	case "cycle":
-		setCycle()
-	case "breathe":
-		setBreathe()
-	case "intro":
-		setIntro()
-	default:
-		flag.Usage()
-		log.Fatalf("Unknown mode: %q", mode)
+func runSolid(args []string) {
+	fs := flag.NewFlagSet("solid", flag.ExitOnError)
+	debug, device := commonFlags(fs)
+	color := fs.String("color", "", "RRGGBB (RGB hex value)")
+	fs.Usage = usageFor(fs, "gled solid [-color <color>] [<color>]")
+	fs.Parse(args)
+
+	c := *color
+	if c == "" {
+		c = fs.Arg(0)
+	}
+	profile := gled.Profile{Mode: "solid", Color: parseColor(fs, c)}
+	applyProfile(profile, *device, *debug)
+}
+
+func runCycle(args []string) {
+	fs := flag.NewFlagSet("cycle", flag.ExitOnError)
+	debug, device := commonFlags(fs)
+	rate := fs.String("rate", "", "100-60000 (Number of milliseconds. Default: 10000ms)")
+	brightness := fs.String("brightness", "", "0-100 (Percentage. Default: 100%)")
+	fs.Usage = usageFor(fs, "gled cycle [-rate <rate>] [-brightness <brightness>] [<rate> [<brightness>]]")
+	fs.Parse(args)
+
+	r, b := *rate, *brightness
+	if r == "" {
+		r = fs.Arg(0)
+	}
+	if b == "" {
+		b = fs.Arg(1)
+	}
+	profile := gled.Profile{
+		Mode:       "cycle",
+		Rate:       parseRate(fs, r),
+		Brightness: parseBrightness(fs, b),
+	}
+	applyProfile(profile, *device, *debug)
+}
+
+func runBreathe(args []string) {
+	fs := flag.NewFlagSet("breathe", flag.ExitOnError)
+	debug, device := commonFlags(fs)
+	color := fs.String("color", "", "RRGGBB (RGB hex value)")
+	rate := fs.String("rate", "", "100-60000 (Number of milliseconds. Default: 10000ms)")
+	brightness := fs.String("brightness", "", "0-100 (Percentage. Default: 100%)")
+	fs.Usage = usageFor(fs, "gled breathe [-color <color>] [-rate <rate>] [-brightness <brightness>] [<color> [<rate> [<brightness>]]]")
+	fs.Parse(args)
+
+	c, r, b := *color, *rate, *brightness
+	if c == "" {
+		c = fs.Arg(0)
+	}
+	if r == "" {
+		r = fs.Arg(1)
+	}
+	if b == "" {
+		b = fs.Arg(2)
+	}
+	profile := gled.Profile{
+		Mode:       "breathe",
+		Color:      parseColor(fs, c),
+		Rate:       parseRate(fs, r),
+		Brightness: parseBrightness(fs, b),
 	}
+	applyProfile(profile, *device, *debug)
 }
 
-func setIntro() {
-	toggle := parseToggle(flag.Arg(1))
-	sendCommand("5b0001" + toggle + "00000000000000")
+func runIntro(args []string) {
+	fs := flag.NewFlagSet("intro", flag.ExitOnError)
+	debug, device := commonFlags(fs)
+	toggle := fs.String("toggle", "", "on|off")
+	fs.Usage = usageFor(fs, "gled intro [-toggle <toggle>] [<toggle>]")
+	fs.Parse(args)
+
+	t := *toggle
+	if t == "" {
+		t = fs.Arg(0)
+	}
+	profile := gled.Profile{Mode: "intro", Toggle: parseToggle(fs, t)}
+	applyProfile(profile, *device, *debug)
+}
+
+func runApply(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	debug, device := commonFlags(fs)
+	profileName := fs.String("profile", "", "Name of a profile defined in the config file. Default: the config file's default_profile")
+	fs.Usage = usageFor(fs, "gled apply [-profile <profile>] [<profile>]")
+	fs.Parse(args)
+
+	name := *profileName
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	profile := profileForName(profileArg(fs, name))
+	applyProfile(profile, *device, *debug)
 }
 
-func setSolid() {
-	c := parseColor(flag.Arg(1))
-	sendCommand("3b0001" + c + "0000000000")
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.Usage = usageFor(fs, "gled list")
+	fs.Parse(args)
+	listProfiles()
 }
 
-func setCycle() {
-	rate := parseRate(flag.Arg(1))
-	brightness := parseBrightness(flag.Arg(2))
-	sendCommand("3b0002" + "0000000000" + rate + brightness)
+func runDevices(args []string) {
+	fs := flag.NewFlagSet("devices", flag.ExitOnError)
+	fs.Usage = usageFor(fs, "gled devices")
+	fs.Parse(args)
+	listDevices()
 }
 
-func setBreathe() {
-	c := parseColor(flag.Arg(1))
-	rate := parseRate(flag.Arg(2))
-	brightness := parseBrightness(flag.Arg(3))
-	sendCommand("3b0003" + c + rate + "00" + brightness + "00")
+func runDaemonCmd(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	debug, device := commonFlags(fs)
+	fs.Usage = usageFor(fs, "gled daemon")
+	fs.Parse(args)
+	runDaemon(*device, *debug)
 }
 
-func sendCommand(data string) {
-	fullData := fmt.Sprintf(format, data)
-	log.Printf("Sending command: %s", fullData)
-	payload, err := hex.DecodeString(fullData)
+func runTimelineCmd(args []string) {
+	fs := flag.NewFlagSet("timeline", flag.ExitOnError)
+	fs.Usage = usageFor(fs, "gled timeline <name>")
+	fs.Parse(args)
+
+	name := fs.Arg(0)
+	if name == "" {
+		fs.Usage()
+		log.Fatal("No timeline argument found")
+	}
+	if err := sendSocket(gled.Command{Timeline: name}); err != nil {
+		log.Fatalf("Error running timeline %q (is 'gled daemon' running?): %v", name, err)
+	}
+}
+
+// applyProfile sends profile to the running daemon if one is listening,
+// otherwise opens device (by name, vid:pid, or "" to auto-detect) directly
+// and applies it there.
+func applyProfile(profile gled.Profile, device string, debugLevel int) {
+	err := sendSocket(gled.Command{Profile: profile})
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, errNoDaemon) {
+		log.Fatalf("Error sending command to daemon: %v", err)
+	}
+
+	effect, err := profile.Effect()
 	if err != nil {
-		log.Fatalf("Error converting data from hex string: %v", err)
+		log.Fatalf("Error building effect: %v", err)
 	}
 
-	// Only one context should be needed for an application.  It should always be closed.
-	ctx := gousb.NewContext()
-	defer ctx.Close()
-	// Debugging can be turned on; this shows some of the inner workings of the libusb package.
-	ctx.Debug(*debug)
+	dev, err := gled.OpenDevice(device, debugLevel)
+	if err != nil {
+		log.Fatalf("Error opening device: %v", err)
+	}
+	defer dev.Close()
+
+	if err := dev.Apply(effect); err != nil {
+		log.Fatalf("Error applying effect: %v", err)
+	}
+}
+
+// sendSocket sends cmd to the daemon's socket and waits for its reply. It
+// returns errNoDaemon if no daemon is listening.
+func sendSocket(cmd gled.Command) error {
+	path, err := gled.SocketPath()
+	if err != nil {
+		return errNoDaemon
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return errNoDaemon
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(socketCommandTimeout)); err != nil {
+		return fmt.Errorf("set socket deadline: %w", err)
+	}
+
+	if err := json.NewEncoder(conn).Encode(cmd); err != nil {
+		return fmt.Errorf("send command: %w", err)
+	}
+	var reply gled.Reply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return fmt.Errorf("read reply: %w", err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("daemon: %s", reply.Error)
+	}
+	return nil
+}
 
-	dev, err := ctx.OpenDeviceWithVIDPID(vendorId, productId)
+// runDaemon opens the device once and serves commands over a Unix socket
+// until interrupted.
+func runDaemon(device string, debugLevel int) {
+	dev, err := gled.OpenDevice(device, debugLevel)
 	if err != nil {
-		log.Fatalf("Error open device: %v", err)
+		log.Fatalf("Error opening device: %v", err)
 	}
 	defer dev.Close()
-	// reset device is very important before send new control command in sequence command executions
-	defer dev.Reset()
 
-	if err := dev.SetAutoDetach(true); err != nil {
-		log.Fatalf("Error set auto detach kernel for device: %v", err)
+	cfg := loadConfigOrEmpty()
+
+	socketPath, err := gled.SocketPath()
+	if err != nil {
+		log.Fatalf("Error locating daemon socket: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigc
+		cancel()
+	}()
+
+	log.Printf("Listening on %s", socketPath)
+	d := gled.NewDaemon(dev, cfg)
+	if err := d.Serve(ctx, socketPath); err != nil {
+		log.Fatalf("Error serving daemon: %v", err)
 	}
+}
 
-	// Claim the default interface using a convenience function.
-	// The default interface is always #0 alt #0 in the currently active
-	// config.
-	_, done, err := dev.DefaultInterface()
+// profileArg resolves the profile name to apply: the explicit name if given,
+// otherwise the config file's default_profile.
+func profileArg(fs *flag.FlagSet, name string) string {
+	if name != "" {
+		return name
+	}
+	cfg := loadConfig()
+	if cfg.DefaultProfile == "" {
+		fs.Usage()
+		log.Fatal("No profile given and no default_profile configured")
+	}
+	return cfg.DefaultProfile
+}
+
+// profileForName looks up name in the config file.
+func profileForName(name string) gled.Profile {
+	cfg := loadConfig()
+	profile, err := cfg.Profile(name)
 	if err != nil {
-		log.Fatalf("Error claim default interface: %v", err)
+		log.Fatalf("Error applying profile: %v", err)
 	}
-	defer done()
+	return profile
+}
 
-	n, err := dev.Control(0x21, 0x09, 0x0211, 0x01, payload)
+// listProfiles prints the profiles available in the config file.
+func listProfiles() {
+	cfg := loadConfig()
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		marker := ""
+		if name == cfg.DefaultProfile {
+			marker = " (default)"
+		}
+		fmt.Printf("%s%s\n", name, marker)
+	}
+}
+
+// listDevices prints the supported mice currently connected over USB.
+func listDevices() {
+	connected, err := gled.ConnectedDevices()
 	if err != nil {
-		log.Fatalf("Error sending control data: %v", err)
+		log.Fatalf("Error listing devices: %v", err)
+	}
+	if len(connected) == 0 {
+		fmt.Println("No supported devices connected")
+		return
+	}
+	for _, p := range connected {
+		fmt.Printf("%s (%s:%s)\n", p.Name, p.VID, p.PID)
 	}
+}
 
-	log.Printf("%d bytes transferred to device", n)
+// loadConfig loads the config file from its default location.
+func loadConfig() *gled.Config {
+	path, err := gled.ConfigPath()
+	if err != nil {
+		log.Fatalf("Error locating config file: %v", err)
+	}
+	cfg, err := gled.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Error loading config file %s: %v", path, err)
+	}
+	return cfg
 }
 
-func parseToggle(toggleArg string) string {
-	var toggle string
+// loadConfigOrEmpty loads the config file if present, or returns an empty
+// Config if it does not exist; the daemon can run with no profiles or
+// timelines configured.
+func loadConfigOrEmpty() *gled.Config {
+	path, err := gled.ConfigPath()
+	if err != nil {
+		log.Fatalf("Error locating config file: %v", err)
+	}
+	cfg, err := gled.LoadConfig(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &gled.Config{}
+		}
+		log.Fatalf("Error loading config file %s: %v", path, err)
+	}
+	return cfg
+}
+
+func parseToggle(fs *flag.FlagSet, toggleArg string) string {
 	switch toggleArg {
-	case "on":
-		toggle = "01"
-	case "off":
-		toggle = "02"
+	case "on", "off":
+		return toggleArg
 	default:
-		flag.Usage()
+		fs.Usage()
 		log.Fatalf("Error parsing toggle argument: %q", toggleArg)
+		return ""
 	}
-	return toggle
 }
 
-func parseColor(colorArg string) string {
+func parseColor(fs *flag.FlagSet, colorArg string) string {
 	if colorArg == "" {
-		flag.Usage()
+		fs.Usage()
 		log.Fatal("No color argument found")
 	}
-	if !strings.HasPrefix(colorArg, "#") {
-		colorArg = "#" + colorArg
-	}
-	c, err := parseHexColor(colorArg)
+	c, err := gled.ParseColor(colorArg)
 	if err != nil {
-		flag.Usage()
+		fs.Usage()
 		log.Fatalf("Error parsing color argument: %q: %v", colorArg, err)
 	}
 	return fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
 }
 
-func parseRate(rateArg string) string {
-	var rate int
+func parseRate(fs *flag.FlagSet, rateArg string) int {
 	if rateArg == "" {
-		rate = defaultRate
-	} else {
-		var err error
-		rate, err = strconv.Atoi(rateArg)
-		if err != nil {
-			flag.Usage()
-			log.Fatalf("Error parsing rate argument: %q: %v", rateArg, err)
-		}
-		if rate < 100 || rate > 60000 {
-			flag.Usage()
-			log.Fatalf("Rate argument: %q is out of range", rateArg)
-		}
+		return gled.DefaultRate
+	}
+	rate, err := strconv.Atoi(rateArg)
+	if err != nil {
+		fs.Usage()
+		log.Fatalf("Error parsing rate argument: %q: %v", rateArg, err)
+	}
+	if rate < 100 || rate > 60000 {
+		fs.Usage()
+		log.Fatalf("Rate argument: %q is out of range", rateArg)
 	}
-	return fmt.Sprintf("%04x", rate)
+	return rate
 }
 
-func parseBrightness(brightnessArg string) string {
-	var brightness int
+func parseBrightness(fs *flag.FlagSet, brightnessArg string) int {
 	if brightnessArg == "" {
-		brightness = defaultBrightness
-	} else {
-		var err error
-		brightness, err = strconv.Atoi(brightnessArg)
-		if err != nil {
-			flag.Usage()
-			log.Fatalf("Error parsing brightness argument: %q: %v", brightnessArg, err)
-		}
-		if brightness < 1 || brightness > 100 {
-			flag.Usage()
-			log.Fatalf("Brightness argument: %q is out of range", brightnessArg)
-		}
+		return gled.DefaultBrightness
 	}
-	return fmt.Sprintf("%02x", brightness)
-}
-
-func parseHexColor(s string) (c color.RGBA, err error) {
-	c.A = 0xff
-	switch len(s) {
-	case 7:
-		_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
-	case 4:
-		_, err = fmt.Sscanf(s, "#%1x%1x%1x", &c.R, &c.G, &c.B)
-		// Double the hex digits:
-		c.R *= 17
-		c.G *= 17
-		c.B *= 17
-	default:
-		err = fmt.Errorf("error parsing HEX color string")
+	brightness, err := strconv.Atoi(brightnessArg)
+	if err != nil {
+		fs.Usage()
+		log.Fatalf("Error parsing brightness argument: %q: %v", brightnessArg, err)
+	}
+	if brightness < 1 || brightness > 100 {
+		fs.Usage()
+		log.Fatalf("Brightness argument: %q is out of range", brightnessArg)
 	}
-	return
+	return brightness
 }
@@ -0,0 +1,30 @@
+package gled
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// ParseColor parses an RRGGBB or RGB hex color string, with or without a
+// leading "#", into an opaque color.RGBA.
+func ParseColor(s string) (color.RGBA, error) {
+	if !strings.HasPrefix(s, "#") {
+		s = "#" + s
+	}
+	c := color.RGBA{A: 0xff}
+	var err error
+	switch len(s) {
+	case 7:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 4:
+		_, err = fmt.Sscanf(s, "#%1x%1x%1x", &c.R, &c.G, &c.B)
+		// Double the hex digits:
+		c.R *= 17
+		c.G *= 17
+		c.B *= 17
+	default:
+		err = fmt.Errorf("error parsing HEX color string")
+	}
+	return c, err
+}
@@ -0,0 +1,98 @@
+package gled
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named, reusable lighting configuration that can be applied
+// in one step, e.g. via `gled apply <name>`. It also doubles as the shape
+// of a daemon socket command, which is why its fields carry both yaml tags
+// (config file) and json tags (socket protocol).
+type Profile struct {
+	Mode       string `yaml:"mode" json:"mode"`
+	Color      string `yaml:"color,omitempty" json:"color,omitempty"`
+	Rate       int    `yaml:"rate,omitempty" json:"rate,omitempty"`
+	Brightness int    `yaml:"brightness,omitempty" json:"brightness,omitempty"`
+	Toggle     string `yaml:"toggle,omitempty" json:"toggle,omitempty"`
+}
+
+// Config is the on-disk layout of the gled config file.
+type Config struct {
+	DefaultProfile string                    `yaml:"default_profile"`
+	Profiles       map[string]Profile        `yaml:"profiles"`
+	Timelines      map[string][]TimelineStep `yaml:"timelines"`
+}
+
+// ConfigPath returns the default location of the gled config file, honoring
+// XDG_CONFIG_HOME (and its platform equivalents) via os.UserConfigDir.
+func ConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locate user config dir: %w", err)
+	}
+	return filepath.Join(dir, "gled", "config.yml"), nil
+}
+
+// LoadConfig reads and parses the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Profile looks up a named profile, returning an error if it does not exist.
+func (c *Config) Profile(name string) (Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found", name)
+	}
+	return p, nil
+}
+
+// Effect converts the profile into the Effect it represents.
+func (p Profile) Effect() (Effect, error) {
+	switch p.Mode {
+	case "solid":
+		c, err := ParseColor(p.Color)
+		if err != nil {
+			return nil, fmt.Errorf("profile color %q: %w", p.Color, err)
+		}
+		return Solid{Color: c}, nil
+	case "cycle":
+		return Cycle{Rate: rateOrDefault(p.Rate), Brightness: brightnessOrDefault(p.Brightness)}, nil
+	case "breathe":
+		c, err := ParseColor(p.Color)
+		if err != nil {
+			return nil, fmt.Errorf("profile color %q: %w", p.Color, err)
+		}
+		return Breathe{Color: c, Rate: rateOrDefault(p.Rate), Brightness: brightnessOrDefault(p.Brightness)}, nil
+	case "intro":
+		return Intro{Enabled: p.Toggle == "on"}, nil
+	default:
+		return nil, fmt.Errorf("profile mode %q is not a known effect", p.Mode)
+	}
+}
+
+func rateOrDefault(rate int) int {
+	if rate == 0 {
+		return DefaultRate
+	}
+	return rate
+}
+
+func brightnessOrDefault(brightness int) int {
+	if brightness == 0 {
+		return DefaultBrightness
+	}
+	return brightness
+}
@@ -0,0 +1,76 @@
+package gled
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pulseAlertConfig mirrors the timelines example documented in gled.go's
+// usage text.
+const pulseAlertConfig = `
+timelines:
+  pulse-alert:
+    - mode: solid
+      color: ff0000
+      hold: 500ms
+    - mode: solid
+      color: "000000"
+      hold: 500ms
+    - repeat: 5
+`
+
+func TestLoadConfigTimelineHoldDuration(t *testing.T) {
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(pulseAlertConfig), &cfg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	steps, ok := cfg.Timelines["pulse-alert"]
+	if !ok {
+		t.Fatalf("timeline %q not found", "pulse-alert")
+	}
+	if len(steps) != 3 {
+		t.Fatalf("got %d steps, want 3", len(steps))
+	}
+
+	for i, want := range []string{"500ms", "500ms", ""} {
+		if steps[i].Hold != want {
+			t.Errorf("steps[%d].Hold = %q, want %q", i, steps[i].Hold, want)
+		}
+	}
+
+	hold, err := time.ParseDuration(steps[0].Hold)
+	if err != nil {
+		t.Fatalf("ParseDuration(%q): %v", steps[0].Hold, err)
+	}
+	if hold != 500*time.Millisecond {
+		t.Errorf("hold = %v, want 500ms", hold)
+	}
+
+	if steps[2].Repeat != 5 {
+		t.Errorf("steps[2].Repeat = %d, want 5", steps[2].Repeat)
+	}
+}
+
+func TestRunTimelineRepeatsSegmentExactlyRepeatTimes(t *testing.T) {
+	var applied []string
+	apply := func(e Effect) error {
+		applied = append(applied, e.Mode())
+		return nil
+	}
+
+	steps := []TimelineStep{
+		{Profile: Profile{Mode: "solid", Color: "ff0000"}},
+		{Profile: Profile{Mode: "solid", Color: "000000"}},
+		{Repeat: 3},
+	}
+	if err := RunTimeline(apply, steps); err != nil {
+		t.Fatalf("RunTimeline: %v", err)
+	}
+
+	if len(applied) != 6 {
+		t.Fatalf("got %d effects applied, want 6 (2 steps x 3 repeats): %v", len(applied), applied)
+	}
+}
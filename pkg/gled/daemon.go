@@ -0,0 +1,190 @@
+package gled
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SocketPath returns the default location of the daemon's Unix socket,
+// under $XDG_RUNTIME_DIR if set, falling back to os.TempDir otherwise.
+func SocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "gled.sock"), nil
+}
+
+// Command is a single line of the daemon's socket protocol: either a direct
+// effect profile, or the name of a configured timeline.
+type Command struct {
+	Profile
+	Timeline string `json:"timeline,omitempty"`
+}
+
+// Reply is the daemon's response to a Command.
+type Reply struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// TimelineStep is one frame of a Timeline: an effect to hold for Hold before
+// moving to the next step, or, when Repeat is non-zero, a marker that
+// re-runs the steps since the previous repeat marker (or the timeline's
+// start) that many times in total. Hold is a duration string accepted by
+// time.ParseDuration (e.g. "500ms"), not a bare number of nanoseconds.
+type TimelineStep struct {
+	Profile
+	Hold   string `yaml:"hold,omitempty"`
+	Repeat int    `yaml:"repeat,omitempty"`
+}
+
+// RunTimeline plays steps in order, applying each step's effect with apply
+// and blocking for the duration of the whole timeline.
+func RunTimeline(apply func(Effect) error, steps []TimelineStep) error {
+	segmentStart := 0
+	for i, step := range steps {
+		if step.Repeat > 0 {
+			segment := steps[segmentStart:i]
+			for r := 0; r < step.Repeat; r++ {
+				if err := runSegment(apply, segment); err != nil {
+					return err
+				}
+			}
+			segmentStart = i + 1
+			continue
+		}
+	}
+	return runSegment(apply, steps[segmentStart:])
+}
+
+func runSegment(apply func(Effect) error, steps []TimelineStep) error {
+	for _, step := range steps {
+		effect, err := step.Profile.Effect()
+		if err != nil {
+			return err
+		}
+		if err := apply(effect); err != nil {
+			return err
+		}
+		if step.Hold != "" {
+			hold, err := time.ParseDuration(step.Hold)
+			if err != nil {
+				return fmt.Errorf("parse hold %q: %w", step.Hold, err)
+			}
+			time.Sleep(hold)
+		}
+	}
+	return nil
+}
+
+// Daemon keeps a Device claimed and serializes Commands onto it, received
+// as line-delimited JSON over a Unix socket.
+type Daemon struct {
+	dev *Device
+	cfg *Config
+	mu  sync.Mutex
+}
+
+// NewDaemon returns a Daemon that applies commands to dev, resolving
+// timelines against cfg.
+func NewDaemon(dev *Device, cfg *Config) *Daemon {
+	return &Daemon{dev: dev, cfg: cfg}
+}
+
+// Serve listens on socketPath and handles connections until ctx is
+// canceled, at which point it closes the listener and returns nil.
+func (d *Daemon) Serve(ctx context.Context, socketPath string) error {
+	if err := os.RemoveAll(socketPath); err != nil {
+		return fmt.Errorf("remove stale socket %s: %w", socketPath, err)
+	}
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", socketPath, err)
+	}
+	defer l.Close()
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return fmt.Errorf("accept: %w", err)
+			}
+		}
+		go d.handleConn(conn)
+	}
+}
+
+func (d *Daemon) handleConn(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var cmd Command
+		if err := dec.Decode(&cmd); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Error decoding daemon command: %v", err)
+			}
+			return
+		}
+		if err := d.handle(cmd); err != nil {
+			enc.Encode(Reply{Error: err.Error()})
+			continue
+		}
+		enc.Encode(Reply{OK: true})
+	}
+}
+
+func (d *Daemon) handle(cmd Command) error {
+	if cmd.Timeline != "" {
+		steps, ok := d.cfg.Timelines[cmd.Timeline]
+		if !ok {
+			return fmt.Errorf("timeline %q not found", cmd.Timeline)
+		}
+		go d.runTimeline(cmd.Timeline, steps)
+		return nil
+	}
+
+	effect, err := cmd.Profile.Effect()
+	if err != nil {
+		return err
+	}
+	return d.lockedApply(effect)
+}
+
+// lockedApply serializes a single effect application against the device.
+// It is the unit of mutual exclusion: a timeline holds d.mu only for the
+// duration of each of its steps, not for its Hold sleeps in between, so an
+// in-progress timeline doesn't block other commands from being handled —
+// they're simply interleaved with the timeline's remaining steps.
+func (d *Daemon) lockedApply(effect Effect) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dev.Apply(effect)
+}
+
+// runTimeline plays steps in its own goroutine, so a long-running timeline
+// doesn't block the daemon from accepting and acknowledging new
+// connections.
+func (d *Daemon) runTimeline(name string, steps []TimelineStep) {
+	if err := RunTimeline(d.lockedApply, steps); err != nil {
+		log.Printf("Error running timeline %q: %v", name, err)
+	}
+}
@@ -0,0 +1,108 @@
+package gled
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/gousb"
+)
+
+// Device is an open handle to a mouse's USB HID control interface. It holds
+// a persistent gousb.Context for the lifetime of the handle and must be
+// closed when no longer needed.
+type Device struct {
+	ctx     *gousb.Context
+	dev     *gousb.Device
+	profile DeviceProfile
+}
+
+// OpenDevice resolves ref against the registry and currently connected
+// devices, then opens it:
+//   - a non-empty ref is looked up by profile name or "vid:pid" (see FindProfile);
+//   - an empty ref requires exactly one supported device to be connected.
+func OpenDevice(ref string, debugLevel int) (*Device, error) {
+	profile, err := resolveProfile(ref)
+	if err != nil {
+		return nil, err
+	}
+	return Open(profile, debugLevel)
+}
+
+func resolveProfile(ref string) (DeviceProfile, error) {
+	if ref != "" {
+		return FindProfile(ref)
+	}
+	connected, err := ConnectedDevices()
+	if err != nil {
+		return DeviceProfile{}, err
+	}
+	switch len(connected) {
+	case 0:
+		return DeviceProfile{}, fmt.Errorf("no supported device found")
+	case 1:
+		return connected[0], nil
+	default:
+		return DeviceProfile{}, fmt.Errorf("multiple supported devices found, use --device to select one")
+	}
+}
+
+// Open claims the default interface of the first device matching profile.
+// debugLevel is the libusb debug verbosity (0..3).
+func Open(profile DeviceProfile, debugLevel int) (*Device, error) {
+	ctx := gousb.NewContext()
+	ctx.Debug(debugLevel)
+
+	dev, err := ctx.OpenDeviceWithVIDPID(profile.VID, profile.PID)
+	if err != nil {
+		ctx.Close()
+		return nil, fmt.Errorf("open device %s: %w", profile.Name, err)
+	}
+	if dev == nil {
+		ctx.Close()
+		return nil, fmt.Errorf("device %s (%s:%s) not found", profile.Name, profile.VID, profile.PID)
+	}
+
+	if err := dev.SetAutoDetach(true); err != nil {
+		dev.Close()
+		ctx.Close()
+		return nil, fmt.Errorf("set auto detach kernel driver: %w", err)
+	}
+
+	return &Device{ctx: ctx, dev: dev, profile: profile}, nil
+}
+
+// Close releases the device and its USB context.
+func (d *Device) Close() error {
+	defer d.ctx.Close()
+	return d.dev.Close()
+}
+
+// Apply encodes effect for this device's profile and sends it to the
+// control endpoint. The device is reset afterwards, which is required
+// before further commands will be accepted in sequence.
+func (d *Device) Apply(effect Effect) error {
+	payload, err := d.profile.Encode(effect)
+	if err != nil {
+		return err
+	}
+
+	// Claim the default interface using a convenience function.
+	// The default interface is always #0 alt #0 in the currently active
+	// config.
+	_, done, err := d.dev.DefaultInterface()
+	if err != nil {
+		return fmt.Errorf("claim default interface: %w", err)
+	}
+	defer done()
+	// Reset device is very important before sending a new control command
+	// in a sequence of command executions.
+	defer d.dev.Reset()
+
+	n, err := d.dev.Control(0x21, 0x09, 0x0211, 0x01, payload[:])
+	if err != nil {
+		return fmt.Errorf("send control data: %w", err)
+	}
+	log.Printf("%d bytes transferred to device", n)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package gled
+
+import (
+	"image/color"
+)
+
+// Effect is a LED lighting effect. Mode identifies which of a DeviceProfile's
+// command templates to use, and Args supplies the values substituted into
+// it; DeviceProfile.Encode combines the two into the full HID payload. This
+// indirection is what lets the same Effect types drive different mice with
+// different command byte layouts.
+type Effect interface {
+	Mode() string
+	Args() []interface{}
+}
+
+// Solid lights the mouse a single, non-animated color.
+type Solid struct {
+	Color color.RGBA
+}
+
+// Mode implements Effect.
+func (s Solid) Mode() string { return "solid" }
+
+// Args implements Effect.
+func (s Solid) Args() []interface{} { return []interface{}{s.Color.R, s.Color.G, s.Color.B} }
+
+// Cycle animates through all colors at the given rate and brightness.
+type Cycle struct {
+	Rate       int
+	Brightness int
+}
+
+// Mode implements Effect.
+func (c Cycle) Mode() string { return "cycle" }
+
+// Args implements Effect.
+func (c Cycle) Args() []interface{} { return []interface{}{c.Rate, c.Brightness} }
+
+// Breathe fades a single color in and out at the given rate and brightness.
+type Breathe struct {
+	Color      color.RGBA
+	Rate       int
+	Brightness int
+}
+
+// Mode implements Effect.
+func (b Breathe) Mode() string { return "breathe" }
+
+// Args implements Effect.
+func (b Breathe) Args() []interface{} {
+	return []interface{}{b.Color.R, b.Color.G, b.Color.B, b.Rate, b.Brightness}
+}
+
+// Intro enables or disables the mouse's startup lighting effect.
+type Intro struct {
+	Enabled bool
+}
+
+// Mode implements Effect.
+func (i Intro) Mode() string { return "intro" }
+
+// Args implements Effect.
+func (i Intro) Args() []interface{} {
+	toggle := "02"
+	if i.Enabled {
+		toggle = "01"
+	}
+	return []interface{}{toggle}
+}
@@ -0,0 +1,23 @@
+// Package gled drives the LED effects of supported Logitech gaming mice
+// over USB HID control transfers.
+package gled
+
+import (
+	"github.com/google/gousb"
+)
+
+const (
+	// VendorID is the USB vendor ID shared by the supported mice (Logitech, Inc.).
+	VendorID = gousb.ID(0x046d)
+	// ProductID is the USB product ID of the G102 and G203 Prodigy Gaming Mouse.
+	ProductID = gousb.ID(0xc084)
+
+	// DefaultRate is the default cycle/breathe rate in milliseconds.
+	DefaultRate = 10000
+	// DefaultBrightness is the default brightness percentage.
+	DefaultBrightness = 100
+
+	// payloadFormat is the 20-byte HID control payload template; %s is
+	// replaced with the 11-byte, effect-specific command body.
+	payloadFormat = "11ff0e%s000000000000"
+)
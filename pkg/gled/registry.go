@@ -0,0 +1,118 @@
+package gled
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gousb"
+)
+
+// DeviceProfile describes a specific mouse model: its USB identity and the
+// per-effect command templates used to build its HID payload. Templates are
+// fmt verbs keyed by Effect.Mode(), substituted with that Effect's Args().
+//
+// New mice are supported by adding an entry here; the effect dispatch code
+// in main and pkg/gled never needs to change.
+type DeviceProfile struct {
+	VID       gousb.ID
+	PID       gousb.ID
+	Name      string
+	Templates map[string]string
+}
+
+// Registry lists the mice gled knows how to drive.
+var Registry = []DeviceProfile{
+	{
+		VID:  VendorID,
+		PID:  ProductID,
+		Name: "G102/G203 Prodigy",
+		Templates: map[string]string{
+			"solid":   "3b0001%02x%02x%02x0000000000",
+			"cycle":   "3b00020000000000%04x%02x",
+			"breathe": "3b0003%02x%02x%02x%04x00%02x00",
+			"intro":   "5b0001%s00000000000000",
+		},
+	},
+}
+
+// Encode builds the full 20-byte HID payload for effect under this profile.
+func (p DeviceProfile) Encode(effect Effect) ([20]byte, error) {
+	tmpl, ok := p.Templates[effect.Mode()]
+	if !ok {
+		return [20]byte{}, fmt.Errorf("device %q does not support effect %q", p.Name, effect.Mode())
+	}
+	return encode(fmt.Sprintf(tmpl, effect.Args()...))
+}
+
+// encode wraps an effect's command body into the full 20-byte HID payload.
+func encode(data string) ([20]byte, error) {
+	var payload [20]byte
+	full := fmt.Sprintf(payloadFormat, data)
+	b, err := hex.DecodeString(full)
+	if err != nil {
+		return payload, fmt.Errorf("encode payload %q: %w", full, err)
+	}
+	copy(payload[:], b)
+	return payload, nil
+}
+
+// FindProfile looks up a registry entry by human name or by "vid:pid" (hex,
+// no "0x" prefix, e.g. "046d:c084").
+func FindProfile(ref string) (DeviceProfile, error) {
+	if vid, pid, ok := parseVIDPID(ref); ok {
+		for _, p := range Registry {
+			if p.VID == vid && p.PID == pid {
+				return p, nil
+			}
+		}
+		return DeviceProfile{}, fmt.Errorf("no device profile for %s:%s", vid, pid)
+	}
+	for _, p := range Registry {
+		if p.Name == ref {
+			return p, nil
+		}
+	}
+	return DeviceProfile{}, fmt.Errorf("no device profile named %q", ref)
+}
+
+func parseVIDPID(ref string) (vid, pid gousb.ID, ok bool) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	v, err := strconv.ParseUint(parts[0], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	p, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	return gousb.ID(v), gousb.ID(p), true
+}
+
+// ConnectedDevices returns the registry profiles that currently have a
+// matching device plugged in.
+func ConnectedDevices() ([]DeviceProfile, error) {
+	ctx := gousb.NewContext()
+	defer ctx.Close()
+
+	var found []DeviceProfile
+	devs, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		for _, p := range Registry {
+			if desc.Vendor == p.VID && desc.Product == p.PID {
+				found = append(found, p)
+			}
+		}
+		return false
+	})
+	for _, d := range devs {
+		d.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("enumerate usb devices: %w", err)
+	}
+	return found, nil
+}